@@ -34,9 +34,7 @@ func main() {
 	krnl.SetMemoryArg(2, buff)
 
 	// Run the FPGA with the supplied arguments. This is the same for all projects.
-	// The arguments ``(1, 1, 1)`` relate to x, y, z co-ordinates and correspond to our current
-	// underlying technology.
-	krnl.Run(1, 1, 1)
+	krnl.Run()
 
 	// Create a variable for the result from the FPGA and read the result into it.
 	// We have also set an error condition to tell us if the read fails.
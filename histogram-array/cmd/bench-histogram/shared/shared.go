@@ -63,10 +63,13 @@ func doit(world xcl.World, krnl *xcl.Kernel, B *testing.B) {
 	log.Printf("Set arg 2")
 	krnl.SetArg(2, uint32(len(input)))
 	log.Printf("Set arg 3")
+	// Count each sample once, rather than a weighted or 2-D histogram
+	krnl.SetArg(3, uint32(0))
+	log.Printf("Set arg 4")
 
 	log.Printf("Run")
 	B.ResetTimer()
-	krnl.Run(1, 1, 1)
+	krnl.Run()
 	B.StopTimer()
 	log.Printf("Done")
 }
@@ -18,8 +18,32 @@ const (
 	HISTOGRAM_BIT_WIDTH = 9
 	// The resulting number of elements of the histogram
 	HISTOGRAM_WIDTH = 1 << 9
+	// For 2-D histograms, the bit widths we compress x and y to
+	X_BIT_WIDTH = 5
+	Y_BIT_WIDTH = 4
+	// The number of records to send to the FPGA
+	RECORDS = 20
 )
 
+// The kinds of histogram the FPGA can calculate, matching the kernel
+const (
+	MODE_COUNT = iota
+	MODE_WEIGHTED
+	MODE_GRID
+	MODE_GRID_WEIGHTED
+)
+
+// The names of each mode, and the number of values in each of its records
+var modes = []struct {
+	name   string
+	fields int
+}{
+	MODE_COUNT:         {"count", 1},
+	MODE_WEIGHTED:      {"weighted", 2},
+	MODE_GRID:          {"2-D", 2},
+	MODE_GRID_WEIGHTED: {"weighted 2-D", 3},
+}
+
 func main() {
 	// Allocate a 'world' for interacting with the FPGA
 	world := xcl.NewWorld()
@@ -30,14 +54,50 @@ func main() {
 	krnl := world.Import("kernel_test").GetKernel("reconfigure_io_sdaccel_builder_stub_0_1")
 	defer krnl.Release()
 
-	// Define a new array for the data we'll send to the FPGA for processing
-	input := make([]uint32, 20)
-
-	// Seed it with 20 random values, bound to 0 - 2**16
-	for i, _ := range input {
-		input[i] = uint32(uint16(rand.Uint32()))
+	for mode := range modes {
+		// Define a new array of records for the data we'll send to the FPGA
+		// for processing. Samples are bound to 0 - 2**16, and weights to
+		// 0 - 2**10
+		fields := modes[mode].fields
+		input := make([]uint32, RECORDS*fields)
+		for i := range input {
+			input[i] = uint32(uint16(rand.Uint32()))
+			if (mode == MODE_WEIGHTED || mode == MODE_GRID_WEIGHTED) && i%fields == fields-1 {
+				input[i] = uint32(rand.Intn(1 << 10))
+			}
+		}
+
+		output := run(&world, krnl, mode, input)
+
+		// Calculate the same values locally to check the FPGA got it right
+		expected := calculate(mode, input)
+
+		// Return an error if the local and FPGA calculations do not give the same result
+		if !reflect.DeepEqual(expected, output) {
+			log.Fatalf("%s: %v != %v\n", modes[mode].name, output, expected)
+		}
+
+		log.Println()
+		log.Printf("We programmed the FPGA to sort %d records into a %s histogram, and these are the results we got: \n", RECORDS, modes[mode].name)
+
+		// Print out each non-empty bin and coresponding value
+		for i, val := range output {
+			if val == 0 {
+				continue
+			}
+			if mode == MODE_GRID || mode == MODE_GRID_WEIGHTED {
+				x := i >> Y_BIT_WIDTH << (MAX_BIT_WIDTH - X_BIT_WIDTH)
+				y := (i & (1<<Y_BIT_WIDTH - 1)) << (MAX_BIT_WIDTH - Y_BIT_WIDTH)
+				fmt.Printf("(%d, %d): %d\n", x, y, val)
+			} else {
+				fmt.Printf("%d: %d\n", i<<(MAX_BIT_WIDTH-HISTOGRAM_BIT_WIDTH), val)
+			}
+		}
 	}
+}
 
+// run sends the input records to the FPGA, and returns the histogram it calculates
+func run(world *xcl.World, krnl *xcl.Kernel, mode int, input []uint32) [HISTOGRAM_WIDTH]uint32 {
 	// Allocate a space in the shared memory to store the data you're sending to the FPGA
 	buff := world.Malloc(xcl.ReadOnly, uint(binary.Size(input)))
 	defer buff.Free()
@@ -59,37 +119,41 @@ func main() {
 	krnl.SetMemoryArg(0, buff)
 	// Pass the pointer to the memory location reserved for the result as the second argument
 	krnl.SetMemoryArg(1, outputBuff)
-	// Pass the total length of the input as the third argument
-	krnl.SetArg(2, uint32(len(input)))
+	// Pass the number of records in the input as the third argument
+	krnl.SetArg(2, uint32(len(input)/modes[mode].fields))
+	// Pass the kind of histogram to calculate as the fourth argument
+	krnl.SetArg(3, uint32(mode))
 
 	// Run the FPGA with the supplied arguments. This is the same for all projects.
-	// The arguments ``(1, 1, 1)`` relate to x, y, z co-ordinates and correspond to our current
-	// underlying technology.
-	krnl.Run(1, 1, 1)
+	krnl.Run()
 
 	// Read the result from shared memory. If it is zero return an error
 	err := binary.Read(outputBuff.Reader(), binary.LittleEndian, &output)
 	if err != nil {
 		log.Fatal("binary.Read failed:", err)
 	}
+	return output
+}
 
-	// Calculate the same values locally to check the FPGA got it right
+// calculate returns the histogram of the input records in software
+func calculate(mode int, input []uint32) [HISTOGRAM_WIDTH]uint32 {
 	var expected [HISTOGRAM_WIDTH]uint32
-	for _, val := range input {
-		expected[val>>(MAX_BIT_WIDTH-HISTOGRAM_BIT_WIDTH)] += 1
-	}
-
-	// Return an error if the local and FPGA calculations do not give the same result
-	if !reflect.DeepEqual(expected, output) {
-		log.Fatalf("%v != %v\n", output, expected)
+	fields := modes[mode].fields
+	for i := 0; i < len(input); i += fields {
+		index := input[i] >> (MAX_BIT_WIDTH - HISTOGRAM_BIT_WIDTH)
+		weight := uint32(1)
+		switch mode {
+		case MODE_WEIGHTED:
+			weight = input[i+1]
+		case MODE_GRID_WEIGHTED:
+			weight = input[i+2]
+			fallthrough
+		case MODE_GRID:
+			x := input[i] >> (MAX_BIT_WIDTH - X_BIT_WIDTH)
+			y := input[i+1] >> (MAX_BIT_WIDTH - Y_BIT_WIDTH)
+			index = x<<Y_BIT_WIDTH | y
+		}
+		expected[index] += weight
 	}
-
-	log.Println()
-	log.Printf("We programmed the FPGA to sort 20 integers into bins, and these are the results we got: \n")
-
-	// Print out each bin and coresponding value
-	for i, val := range output {
-		fmt.Printf("%d: %d\n", i<<(MAX_BIT_WIDTH-HISTOGRAM_BIT_WIDTH), val)
-	}
-
+	return expected
 }
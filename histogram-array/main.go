@@ -6,11 +6,37 @@ import (
 
 	// Use the SMI protocol package
 	"github.com/ReconfigureIO/sdaccel/smi"
+
+	// Use the histogram library for sorting samples into bins
+	"github.com/ReconfigureIO/histogram"
+)
+
+const (
+	// The maximum bit width we allow samples to have
+	inputBits = 16
+	// The bit width we will compress to, giving 512 bins
+	binBits = 9
+	// For 2-D histograms, the bit widths each field is compressed to,
+	// giving a 32 by 16 grid of the same 512 bins
+	xBinBits = 5
+	yBinBits = 4
+)
+
+// The kind of histogram to calculate, passed by the host as the mode argument
+const (
+	// Each record is one sample, counted once
+	modeCount = iota
+	// Each record is a sample then a weight to add to its bin
+	modeWeighted
+	// Each record is an x and y sample, counted once in a 2-D grid
+	modeGrid
+	// Each record is an x and y sample, then a weight to add to their bin
+	modeGridWeighted
 )
 
 // function to calculate the bin for each sample
 func CalculateIndex(sample uint32) uint16 {
-	return uint16(sample) >> (16 - 9)
+	return uint16(histogram.NewShift(binBits, inputBits).Index(sample))
 }
 
 // magic identifier for exporting
@@ -18,12 +44,13 @@ func Top(
 	// The first set of arguments to this function can be any number
 	// of Go primitive types and can be provided via `SetArg` on the host.
 
-	// For this example, we have 3 arguments: Pointers to the input data, the
-	// space for the result and the length of the input data so the FPGA knows
-	// what to expect.
+	// For this example, we have 4 arguments: Pointers to the input data, the
+	// space for the result, the number of records in the input data so the
+	// FPGA knows what to expect, and the kind of histogram to calculate.
 	inputData uintptr,
 	outputData uintptr,
 	length uint32,
+	mode uint32,
 
 	// Set up channels for interacting with the shared memory
 	readReq chan<- smi.Flit64,
@@ -32,32 +59,30 @@ func Top(
 	writeReq chan<- smi.Flit64,
 	writeResp <-chan smi.Flit64) {
 
-	// Create an array to hold the histogram data as it is sorted
-	histogram := [512]uint32{}
-
-	// Read all of the input data into a channel
-	inputChan := make(chan uint32)
-	go smi.ReadBurstUInt32(readReq, readResp, inputData, smi.DefaultOptions, length, inputChan)
+	// Configure the histogram: samples are sorted by their top 9 bits, or
+	// for a grid, x by its top 5 bits and y by its top 4
+	config := histogram.NewShift(binBits, inputBits)
+	grid := histogram.NewGrid(
+		histogram.NewShift(xBinBits, inputBits),
+		histogram.NewShift(yBinBits, inputBits))
 
-	// The host needs to provide the length we should read
-	for ; length > 0; length-- {
-		// First we'll pull of each sample from the channel
-		sample := <-inputChan
-		// calculate the bin for the histogram
-		index := uint16(sample) >> (16 - 9)
+	// Create an array to hold the histogram data as it is sorted
+	bins := [1 << binBits]uint32{}
 
-		// And increment the value in that bin
-		histogram[uint(index)] += 1
+	// Read all of the input data from shared memory, sorting each record
+	// into its bin as it arrives. The host needs to provide the length we
+	// should read
+	switch mode {
+	case modeWeighted:
+		config.ReadCountWeighted(readReq, readResp, inputData, length, bins[:])
+	case modeGrid:
+		grid.ReadCount(readReq, readResp, inputData, length, bins[:])
+	case modeGridWeighted:
+		grid.ReadCountWeighted(readReq, readResp, inputData, length, bins[:])
+	default:
+		config.ReadCount(readReq, readResp, inputData, length, bins[:])
 	}
 
-	data := make(chan uint32)
-	go func() {
-		for i := 0; i < 512; i++ {
-			data <- histogram[i]
-		}
-	}()
-
 	// Write the results to shared memory
-	smi.WriteBurstUInt32(
-		writeReq, writeResp, outputData, smi.DefaultOptions, 512, data)
+	config.Write(writeReq, writeResp, outputData, bins[:])
 }
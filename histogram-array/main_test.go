@@ -1,8 +1,11 @@
 package main
 
 import (
+	"math/rand"
 	"testing"
 	"testing/quick"
+
+	"github.com/ReconfigureIO/sdaccel/smi/smitest"
 )
 
 func TestCalculateIndexDoesNotOutOfBounds(t *testing.T) {
@@ -15,3 +18,81 @@ func TestCalculateIndexDoesNotOutOfBounds(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestTop(t *testing.T) {
+	const (
+		inputAddr  = 0x10000
+		outputAddr = 0x20000
+	)
+	// Run the kernel against a simulated memory, and check it against a
+	// software histogram as cmd/test-histogram does
+	input := make([]uint32, 1000)
+	for i := range input {
+		input[i] = uint32(uint16(rand.Uint32()))
+	}
+	mem := smitest.NewMemory()
+	mem.WriteUInt32s(inputAddr, input)
+	readReq, readResp := mem.Port()
+	writeReq, writeResp := mem.Port()
+
+	Top(inputAddr, outputAddr, uint32(len(input)), modeCount, readReq, readResp, writeReq, writeResp)
+
+	var expected [512]uint32
+	for _, val := range input {
+		expected[val>>(16-9)] += 1
+	}
+	for i, val := range mem.ReadUInt32s(outputAddr, 512) {
+		if val != expected[i] {
+			t.Errorf("bin %d: got %d, expected %d", i, val, expected[i])
+		}
+	}
+}
+
+func TestTopModes(t *testing.T) {
+	const (
+		inputAddr  = 0x10000
+		outputAddr = 0x20000
+	)
+	// Records of x, y and weight, read as each mode needs
+	input := make([]uint32, 999)
+	for i := range input {
+		input[i] = uint32(uint16(rand.Uint32()))
+	}
+	tests := []struct {
+		mode   uint32
+		fields int
+		index  func(record []uint32) uint32
+		weight func(record []uint32) uint32
+	}{
+		{modeWeighted, 2,
+			func(r []uint32) uint32 { return r[0] >> 7 },
+			func(r []uint32) uint32 { return r[1] }},
+		{modeGrid, 2,
+			func(r []uint32) uint32 { return r[0]>>11<<4 | r[1]>>12 },
+			func(r []uint32) uint32 { return 1 }},
+		{modeGridWeighted, 3,
+			func(r []uint32) uint32 { return r[0]>>11<<4 | r[1]>>12 },
+			func(r []uint32) uint32 { return r[2] }},
+	}
+	for _, test := range tests {
+		mem := smitest.NewMemory()
+		mem.WriteUInt32s(inputAddr, input)
+		readReq, readResp := mem.Port()
+		writeReq, writeResp := mem.Port()
+
+		records := len(input) / test.fields
+		Top(inputAddr, outputAddr, uint32(records), test.mode, readReq, readResp, writeReq, writeResp)
+
+		var expected [512]uint32
+		for i := 0; i < records; i++ {
+			record := input[i*test.fields:]
+			expected[test.index(record)] += test.weight(record)
+		}
+		for i, val := range mem.ReadUInt32s(outputAddr, 512) {
+			if val != expected[i] {
+				t.Errorf("mode %d: bin %d: got %d, expected %d", test.mode, i, val, expected[i])
+				break
+			}
+		}
+	}
+}
@@ -66,7 +66,7 @@ func doit(world xcl.World, krnl *xcl.Kernel, B *testing.B) {
 
 	log.Printf("Run")
 	B.ResetTimer()
-	krnl.Run(1, 1, 1)
+	krnl.Run()
 	B.StopTimer()
 	log.Printf("Done")
 }
@@ -64,9 +64,7 @@ func main() {
 	krnl.SetArg(2, uint32(len(input)))
 
 	// Run the FPGA with the supplied arguments. This is the same for all projects.
-	// The arguments ``(1, 1, 1)`` relate to x, y, z co-ordinates and correspond to our current
-	// underlying technology.
-	krnl.Run(1, 1, 1)
+	krnl.Run()
 
 	// Read the result from shared memory. If it is zero return an error
 	err := binary.Read(outputBuff.Reader(), binary.LittleEndian, &output)
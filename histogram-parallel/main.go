@@ -6,8 +6,29 @@ import (
 
 	// Use the SMI protocol package
 	"github.com/ReconfigureIO/sdaccel/smi"
+
+	// Use the histogram library for binning and merging
+	"github.com/ReconfigureIO/histogram"
+)
+
+const (
+	// The bit width of the samples, and of the bin index
+	inputBits = 16
+	binBits   = 9
+	// The number of bin banks counting in parallel
+	banks = 4
 )
 
+// countBank counts its share of the samples into a local bank of bins, then
+// streams the counts out in order to be merged. No other goroutine touches
+// the bank, so there are no hazards between updates to the same bin.
+func countBank(samples <-chan uint32, length uint32, counts chan<- uint32) {
+	config := histogram.NewShift(binBits, inputBits)
+	bins := [1 << binBits]uint32{}
+	config.Count(bins[:], samples, length)
+	config.Stream(bins[:], counts)
+}
+
 func Top(
 	// For this example, we have 3 arguments: Pointers to the input data, the
 	// space for the result and the length of the input data so the FPGA knows
@@ -28,43 +49,33 @@ func Top(
 	writeReq chan<- smi.Flit64,
 	writeResp <-chan smi.Flit64) {
 
-	readRespChan := make(chan uint32)
-	incrRespChan := make(chan uint32)
-
-	go func() {
-		// Length is the number of addresses we are supposed to read
-		// so this block queues reads from each one in turn.
+	config := histogram.NewShift(binBits, inputBits)
 
-		for i := length; i != 0; i-- {
-			readRespChan <- smi.ReadUInt32(
-				readAReq, readAResp, inputData, smi.DefaultOptions)
-			inputData += 4
-		}
-	}()
+	// Start a goroutine for each bank. Samples are dealt out in turn, so
+	// bank b gets every banks'th sample starting from sample b.
+	var bankSamples [banks]chan uint32
+	var bankCounts [banks]chan uint32
+	for b := uint32(0); b < banks; b++ {
+		bankSamples[b] = make(chan uint32, 16)
+		bankCounts[b] = make(chan uint32)
+		go countBank(bankSamples[b], (length+banks-1-b)/banks, bankCounts[b])
+	}
 
-	go func() {
-		for i := length; i != 0; i-- {
-			// Get the read response that was previously enqueued.
-			sample := <-readRespChan
-			// If we think of external memory we are writing to as a
-			// [512]uint32, this would be the index we access.
-			index := uint16(sample) >> (16 - 9)
-			// And this is that index as a pointer to external memory.
-			outputPointer := outputData + uintptr(index<<2)
-			// Perform an increment operation on that location.
-			current := smi.ReadUInt32(
-				readBReq, readBResp, outputPointer, smi.DefaultOptions)
-			current += 1
-			smi.WriteUInt32(
-				writeReq, writeResp, outputPointer, smi.DefaultOptions, current)
-			incrRespChan <- current
-		}
-	}()
+	// Read the input in bursts, and deal it out to the banks.
+	samples := make(chan uint32)
+	go smi.ReadBurstUInt32(readAReq, readAResp, inputData, smi.DefaultOptions, length, samples)
+	for i := uint32(0); i < length; i++ {
+		bankSamples[i%banks] <- <-samples
+	}
 
-	// Wait for each response for increment operations.
-	for i := length; i != 0; i-- {
-		<-incrRespChan
+	// Start from the histogram already in memory, as the host zeros it, and
+	// merge in each bank.
+	bins := [1 << binBits]uint32{}
+	config.ReadMerge(readBReq, readBResp, outputData, bins[:])
+	for b := 0; b < banks; b++ {
+		config.MergeStream(bins[:], bankCounts[b])
 	}
 
-	// Once that's done, we can exit.
+	// Write the merged histogram back, and we're done.
+	config.Write(writeReq, writeResp, outputData, bins[:])
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ReconfigureIO/sdaccel/smi/smitest"
+)
+
+const (
+	inputAddr  = 0x10000
+	outputAddr = 0x20000
+)
+
+// run runs Top on input against a simulated memory, with the output
+// histogram initially holding initial, and returns the histogram it writes.
+func run(input []uint32, initial []uint32) []uint32 {
+	mem := smitest.NewMemory()
+	mem.WriteUInt32s(inputAddr, input)
+	mem.WriteUInt32s(outputAddr, initial)
+	readAReq, readAResp := mem.Port()
+	readBReq, readBResp := mem.Port()
+	writeReq, writeResp := mem.Port()
+
+	Top(inputAddr, outputAddr, uint32(len(input)),
+		readAReq, readAResp, readBReq, readBResp, writeReq, writeResp)
+	return mem.ReadUInt32s(outputAddr, 1<<binBits)
+}
+
+// softwareHistogram is the software histogram cmd/test-histogram checks against.
+func softwareHistogram(input []uint32, initial []uint32) []uint32 {
+	expected := append([]uint32{}, initial...)
+	for _, val := range input {
+		expected[uint16(val)>>(inputBits-binBits)] += 1
+	}
+	return expected
+}
+
+func TestTop(t *testing.T) {
+	random := func(n int, f func() uint32) []uint32 {
+		input := make([]uint32, n)
+		for i := range input {
+			input[i] = f()
+		}
+		return input
+	}
+	sameBin := func() uint32 { return 0x1234 }
+	twoBins := func() uint32 { return uint32(rand.Intn(2)) << 15 }
+	uniform := func() uint32 { return uint32(uint16(rand.Uint32())) }
+	// Runs of equal samples, which land on every bank in turn.
+	runs := make([]uint32, 1000)
+	for i := range runs {
+		runs[i] = uint32(i/banks) << (inputBits - binBits)
+	}
+
+	tests := map[string][]uint32{
+		"empty":     nil,
+		"single":    {0xffff},
+		"one short": random(banks-1, uniform),
+		"uniform":   random(1000, uniform),
+		"same bin":  random(1001, sameBin),
+		"two bins":  random(1002, twoBins),
+		"runs":      runs,
+		"high bits": random(503, rand.Uint32),
+	}
+	zeros := make([]uint32, 1<<binBits)
+	for name, input := range tests {
+		got, expected := run(input, zeros), softwareHistogram(input, zeros)
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("%s: bin %d is %d, expected %d", name, i, got[i], expected[i])
+				break
+			}
+		}
+	}
+}
+
+func TestTopAccumulates(t *testing.T) {
+	// The kernel adds to the histogram in memory, like the read-modify-write
+	// it replaces.
+	initial := make([]uint32, 1<<binBits)
+	for i := range initial {
+		initial[i] = uint32(i)
+	}
+	input := []uint32{0, 1, 0x8000, 0xffff, 0xffff}
+	got, expected := run(input, initial), softwareHistogram(input, initial)
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("bin %d is %d, expected %d", i, got[i], expected[i])
+		}
+	}
+}
@@ -22,11 +22,14 @@ func main() {
 	krnl := world.Import("kernel_test").GetKernel("reconfigure_io_sdaccel_builder_stub_0_1")
 	defer krnl.Release()
 
-	memcpy := func(input [DATA_WIDTH]uint64) bool {
+	memcpy := func(input [DATA_WIDTH]uint64, length uint8) bool {
 
 		byteLength := uint(binary.Size(input))
 
-		outputBuff := world.Malloc(xcl.WriteOnly, byteLength)
+		// Copy any number of bytes, not just whole words
+		copyLength := uint32(length) % uint32(byteLength+1)
+
+		outputBuff := world.Malloc(xcl.ReadWrite, byteLength)
 		defer outputBuff.Free()
 
 		inputBuff := world.Malloc(xcl.ReadOnly, byteLength)
@@ -34,24 +37,33 @@ func main() {
 
 		binary.Write(inputBuff.Writer(), binary.LittleEndian, &input)
 
+		// Zero the output, so we can check nothing past the copy is written
+		var ret [DATA_WIDTH]uint64
+		binary.Write(outputBuff.Writer(), binary.LittleEndian, &ret)
+
 		krnl.SetMemoryArg(0, inputBuff)
 		krnl.SetMemoryArg(1, outputBuff)
-		krnl.SetArg(2, uint32(len(input)))
+		krnl.SetArg(2, copyLength)
 
-		krnl.Run(1, 1, 1)
+		krnl.Run()
 
-		var ret [DATA_WIDTH]uint64
 		err := binary.Read(outputBuff.Reader(), binary.LittleEndian, &ret)
 
 		log.Printf("Input: %v", input)
-		log.Printf("Result: %v", ret)
+		log.Printf("Copied %d bytes: %v", copyLength, ret)
 
 		if err != nil {
 			log.Fatal("binary.Read failed:", err)
 		}
 
-		if !reflect.DeepEqual(ret, input) {
-			log.Printf("%v != %v", ret, input)
+		// Only the first copyLength bytes of the input are expected
+		var expected [DATA_WIDTH]uint64
+		for i := uint32(0); i < copyLength; i++ {
+			expected[i/8] |= input[i/8] & (0xFF << (8 * (i % 8)))
+		}
+
+		if !reflect.DeepEqual(ret, expected) {
+			log.Printf("%v != %v", ret, expected)
 			return false
 		}
 		return true
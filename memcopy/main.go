@@ -8,6 +8,8 @@ import (
 
 // Magic identifier for exporting
 func Top(
+	// The source and destination may have any byte alignment, and length is
+	// the number of bytes to copy.
 	inputData uintptr,
 	outputData uintptr,
 	length uint32,
@@ -19,9 +21,7 @@ func Top(
 	writeReq chan<- smi.Flit64,
 	writeResp <-chan smi.Flit64) {
 
-	data := make(chan uint64)
-	go smi.ReadBurstUInt64(
-		readReq, readResp, inputData, smi.DefaultOptions, length, data)
-	smi.WriteBurstUInt64(
-		writeReq, writeResp, outputData, smi.DefaultOptions, length, data)
+	smi.Memcpy(
+		readReq, readResp, writeReq, writeResp,
+		outputData, inputData, smi.DefaultOptions, length)
 }
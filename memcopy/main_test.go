@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"testing/quick"
+
+	"github.com/ReconfigureIO/sdaccel/smi/smitest"
+)
+
+func TestTop(t *testing.T) {
+	const (
+		inputAddr  = 0x10000
+		outputAddr = 0x20000
+	)
+	f := func(input []byte, inputOffset, outputOffset uint8) bool {
+		in := uint64(inputAddr) + uint64(inputOffset%16)
+		out := uint64(outputAddr) + uint64(outputOffset%16)
+		mem := smitest.NewMemory()
+		mem.Write(in, input)
+		readReq, readResp := mem.Port()
+		writeReq, writeResp := mem.Port()
+
+		Top(uintptr(in), uintptr(out), uint32(len(input)), readReq, readResp, writeReq, writeResp)
+
+		// Check the bytes either side are untouched too.
+		got := mem.Read(out-1, len(input)+2)
+		return got[0] == 0 && got[len(got)-1] == 0 && bytes.Equal(got[1:len(got)-1], input)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/adler32"
+	"hash/crc32"
+	"log"
+	"math/rand"
+
+	"github.com/ReconfigureIO/sdaccel/xcl"
+)
+
+// The number of uint32s in each buffer
+const DATA_WIDTH = 1024
+
+// The bulk memory operations, matching the kernel
+const (
+	OP_MEMSET = iota
+	OP_SEQUENCE
+	OP_MEMCMP
+	OP_CRC32
+	OP_ADLER32
+)
+
+func main() {
+	world := xcl.NewWorld()
+	defer world.Release()
+
+	krnl := world.Import("kernel_test").GetKernel("reconfigure_io_sdaccel_builder_stub_0_1")
+	defer krnl.Release()
+
+	// Fill the buffers with random data, differing from a random index on
+	var input [DATA_WIDTH]uint32
+	for i := range input {
+		input[i] = rand.Uint32()
+	}
+	other := input
+	mismatch := rand.Intn(DATA_WIDTH)
+	other[mismatch] ^= 1 << uint(rand.Intn(32))
+
+	inputBuff := world.Malloc(xcl.ReadWrite, uint(binary.Size(input)))
+	defer inputBuff.Free()
+	otherBuff := world.Malloc(xcl.ReadOnly, uint(binary.Size(other)))
+	defer otherBuff.Free()
+	outputBuff := world.Malloc(xcl.ReadWrite, 8)
+	defer outputBuff.Free()
+
+	binary.Write(otherBuff.Writer(), binary.LittleEndian, &other)
+
+	// run writes the input to the FPGA, performs op on it, and returns the
+	// result and the final contents of the input buffer
+	run := func(op uint32, pattern uint32) (uint32, [DATA_WIDTH]uint32) {
+		binary.Write(inputBuff.Writer(), binary.LittleEndian, &input)
+
+		krnl.SetArg(0, op)
+		krnl.SetMemoryArg(1, inputBuff)
+		krnl.SetMemoryArg(2, otherBuff)
+		krnl.SetMemoryArg(3, outputBuff)
+		krnl.SetArg(4, uint32(DATA_WIDTH))
+		krnl.SetArg(5, pattern)
+
+		krnl.Run()
+
+		var output [2]uint32
+		err := binary.Read(outputBuff.Reader(), binary.LittleEndian, &output)
+		if err != nil {
+			log.Fatal("binary.Read failed:", err)
+		}
+		if output[1] != 1 {
+			log.Fatalf("Operation %d reported a memory error", op)
+		}
+
+		var buffer [DATA_WIDTH]uint32
+		err = binary.Read(inputBuff.Reader(), binary.LittleEndian, &buffer)
+		if err != nil {
+			log.Fatal("binary.Read failed:", err)
+		}
+		return output[0], buffer
+	}
+
+	// Check memset and the sequence pattern
+	pattern := rand.Uint32()
+	_, buffer := run(OP_MEMSET, pattern)
+	for i, val := range buffer {
+		if val != pattern {
+			log.Fatalf("memset: word %d is %#x, expected %#x", i, val, pattern)
+		}
+	}
+	log.Printf("memset: filled %d words with %#x", DATA_WIDTH, pattern)
+
+	_, buffer = run(OP_SEQUENCE, pattern)
+	for i, val := range buffer {
+		if val != pattern+uint32(i) {
+			log.Fatalf("sequence: word %d is %#x, expected %#x", i, val, pattern+uint32(i))
+		}
+	}
+	log.Printf("sequence: filled %d words counting from %#x", DATA_WIDTH, pattern)
+
+	// Check memcmp finds the mismatch
+	result, _ := run(OP_MEMCMP, 0)
+	if result != uint32(mismatch) {
+		log.Fatalf("memcmp: first mismatch at %d, expected %d", result, mismatch)
+	}
+	log.Printf("memcmp: first mismatch at %d", result)
+
+	// Check the checksums against Go's
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, &input)
+
+	result, _ = run(OP_CRC32, 0)
+	if expected := crc32.ChecksumIEEE(data.Bytes()); result != expected {
+		log.Fatalf("CRC-32: %#08x != %#08x", result, expected)
+	}
+	log.Printf("CRC-32: %#08x", result)
+
+	result, _ = run(OP_ADLER32, 0)
+	if expected := adler32.Checksum(data.Bytes()); result != expected {
+		log.Fatalf("Adler-32: %#08x != %#08x", result, expected)
+	}
+	log.Printf("Adler-32: %#08x", result)
+}
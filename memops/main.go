@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/ReconfigureIO/sdaccel/smi"
+)
+
+// The bulk memory operations, selected by the host with the op argument
+const (
+	// Fill buffer A with the pattern
+	opMemset = iota
+	// Fill buffer A with pattern, pattern+1, pattern+2 and so on
+	opSequence
+	// Compare buffers A and B, giving the index of the first mismatch
+	opMemcmp
+	// Checksum buffer A with CRC-32
+	opCRC32
+	// Checksum buffer A with Adler-32
+	opAdler32
+)
+
+// Magic identifier for exporting
+func Top(
+	// The operation to perform, the buffers to operate on and the space for
+	// the result, the length of the buffers in uint32s, and the pattern to
+	// fill buffer A with.
+	op uint32,
+	bufferA uintptr,
+	bufferB uintptr,
+	outputData uintptr,
+	length uint32,
+	pattern uint32,
+
+	// Set up channels for interacting with the shared memory, two read
+	// ports for the buffers and one write port
+	readAReq chan<- smi.Flit64,
+	readAResp <-chan smi.Flit64,
+
+	readBReq chan<- smi.Flit64,
+	readBResp <-chan smi.Flit64,
+
+	writeReq chan<- smi.Flit64,
+	writeResp <-chan smi.Flit64) {
+
+	var result uint32
+	var ok bool
+	switch op {
+	case opMemset:
+		ok = smi.Memset(writeReq, writeResp, bufferA, smi.DefaultOptions, length, pattern)
+	case opSequence:
+		ok = smi.MemsetSequence(writeReq, writeResp, bufferA, smi.DefaultOptions, length, pattern, 1)
+	case opMemcmp:
+		result, ok = smi.Memcmp(
+			readAReq, readAResp, readBReq, readBResp, bufferA, bufferB, smi.DefaultOptions, length)
+	case opCRC32:
+		result, ok = smi.CRC32(readAReq, readAResp, bufferA, smi.DefaultOptions, length)
+	case opAdler32:
+		result, ok = smi.Adler32(readAReq, readAResp, bufferA, smi.DefaultOptions, length)
+	}
+
+	// Write the result, followed by 1 if the operation succeeded or 0 if it
+	// failed
+	status := uint32(0)
+	if ok {
+		status = 1
+	}
+	smi.WriteUInt32(writeReq, writeResp, outputData, smi.DefaultOptions, result)
+	smi.WriteUInt32(writeReq, writeResp, outputData+4, smi.DefaultOptions, status)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/adler32"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/ReconfigureIO/sdaccel/smi"
+	"github.com/ReconfigureIO/sdaccel/smi/smitest"
+)
+
+const (
+	bufferAAddr = 0x10000
+	bufferBAddr = 0x20000
+	outputAddr  = 0x30000
+	length      = 300
+)
+
+// run runs Top against mem, returning the result and status it writes.
+func run(mem *smitest.Memory, op uint32, pattern uint32) (uint32, uint32) {
+	readAReq, readAResp := mem.Port()
+	writeReq, writeResp := mem.Port()
+	return runPorts(mem, op, pattern, readAReq, readAResp, writeReq, writeResp)
+}
+
+// runPorts runs Top against mem, using the given ports for buffer A and
+// writing.
+func runPorts(mem *smitest.Memory, op uint32, pattern uint32,
+	readAReq chan<- smi.Flit64, readAResp <-chan smi.Flit64,
+	writeReq chan<- smi.Flit64, writeResp <-chan smi.Flit64) (uint32, uint32) {
+	readBReq, readBResp := mem.Port()
+	Top(op, bufferAAddr, bufferBAddr, outputAddr, length, pattern,
+		readAReq, readAResp, readBReq, readBResp, writeReq, writeResp)
+	output := mem.ReadUInt32s(outputAddr, 2)
+	return output[0], output[1]
+}
+
+func TestMemset(t *testing.T) {
+	mem := smitest.NewMemory()
+	if _, status := run(mem, opMemset, 0xC0FFEE); status != 1 {
+		t.Fatal("memset failed")
+	}
+	for i, v := range mem.ReadUInt32s(bufferAAddr, length) {
+		if v != 0xC0FFEE {
+			t.Fatalf("word %d is %#x, expected 0xc0ffee", i, v)
+		}
+	}
+
+	if _, status := run(mem, opSequence, 100); status != 1 {
+		t.Fatal("sequence failed")
+	}
+	for i, v := range mem.ReadUInt32s(bufferAAddr, length) {
+		if v != uint32(100+i) {
+			t.Fatalf("word %d is %d, expected %d", i, v, 100+i)
+		}
+	}
+}
+
+func TestMemcmp(t *testing.T) {
+	data := make([]uint32, length)
+	for i := range data {
+		data[i] = uint32(i * i)
+	}
+	mem := smitest.NewMemory()
+	mem.WriteUInt32s(bufferAAddr, data)
+	mem.WriteUInt32s(bufferBAddr, data)
+	if got, status := run(mem, opMemcmp, 0); status != 1 || got != length {
+		t.Errorf("equal buffers gave %d, status %d; expected %d", got, status, length)
+	}
+	mem.WriteUInt32s(bufferBAddr+4*123, []uint32{1})
+	if got, status := run(mem, opMemcmp, 0); status != 1 || got != 123 {
+		t.Errorf("mismatch at 123 gave %d, status %d", got, status)
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	data := make([]uint32, length)
+	for i := range data {
+		data[i] = uint32(i) * 0x01000193
+	}
+	b := make([]byte, 4*length)
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	mem := smitest.NewMemory()
+	mem.WriteUInt32s(bufferAAddr, data)
+	if got, status := run(mem, opCRC32, 0); status != 1 || got != crc32.ChecksumIEEE(b) {
+		t.Errorf("CRC-32 gave %#x, status %d; expected %#x", got, status, crc32.ChecksumIEEE(b))
+	}
+	if got, status := run(mem, opAdler32, 0); status != 1 || got != adler32.Checksum(b) {
+		t.Errorf("Adler-32 gave %#x, status %d; expected %#x", got, status, adler32.Checksum(b))
+	}
+}
+
+func TestFaults(t *testing.T) {
+	data := make([]uint32, length)
+	for i := range data {
+		data[i] = uint32(i) * 0x01000193
+	}
+	b := make([]byte, 4*length)
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	delays := smitest.Faults{Seed: 1, Delay: 0.5, MaxDelay: 50 * time.Microsecond}
+	failFirst := smitest.Faults{At: map[int]smitest.Fault{0: smitest.FaultError}}
+	failLast := smitest.Faults{At: map[int]smitest.Fault{4: smitest.FaultError}}
+
+	cases := []struct {
+		name string
+		op   uint32
+		// The faults to inject on buffer A's port, or on the write port
+		// for the memsets.
+		faults smitest.Faults
+		result uint32
+		status uint32
+	}{
+		{"delayed CRC-32", opCRC32, delays, crc32.ChecksumIEEE(b), 1},
+		{"failed CRC-32", opCRC32, failLast, 0, 0},
+		{"delayed Adler-32", opAdler32, delays, adler32.Checksum(b), 1},
+		{"failed Adler-32", opAdler32, failFirst, 0, 0},
+		{"delayed memcmp", opMemcmp, delays, length, 1},
+		{"failed memcmp", opMemcmp, failFirst, 0, 0},
+		{"delayed memset", opMemset, delays, 0, 1},
+		{"failed memset", opMemset, failFirst, 0, 0},
+	}
+	for _, tc := range cases {
+		mem := smitest.NewMemory()
+		mem.WriteUInt32s(bufferAAddr, data)
+		mem.WriteUInt32s(bufferBAddr, data)
+		var port *smitest.FaultyPort
+		var result, status uint32
+		if tc.op == opMemset {
+			port = smitest.NewFaultyPort(mem, tc.faults)
+			readAReq, readAResp := mem.Port()
+			result, status = runPorts(mem, tc.op, 0xC0FFEE, readAReq, readAResp, port.Req, port.Resp)
+		} else {
+			port = smitest.NewFaultyPort(mem, tc.faults)
+			writeReq, writeResp := mem.Port()
+			result, status = runPorts(mem, tc.op, 0, port.Req, port.Resp, writeReq, writeResp)
+		}
+		if tc.status == 1 && (status != 1 || result != tc.result) {
+			t.Errorf("%s gave %#x, status %d; expected %#x", tc.name, result, status, tc.result)
+		}
+		if tc.status == 0 && status != 0 {
+			t.Errorf("%s succeeded after faults %v", tc.name, port.Injected())
+		}
+		if len(port.Injected()) == 0 {
+			t.Errorf("%s: no faults were injected", tc.name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ReconfigureIO/sdaccel/xcl"
+)
+
+// The number of 64-bit words in the shared region, and the number of passes
+// each client makes over it
+const (
+	REGION_WORDS = 32
+	ITERATIONS   = 16
+)
+
+// The layout of the results written by the kernel: a header of 8 64-bit
+// words, followed by a row of 8 64-bit words for each of 4 clients
+const (
+	RESULT_HEADER_SIZE = 64
+	RESULT_ROW_SIZE    = 64
+	MAX_CLIENTS        = 4
+)
+
+func main() {
+	world := xcl.NewWorld()
+	defer world.Release()
+
+	krnl := world.Import("kernel_test").GetKernel("reconfigure_io_sdaccel_builder_stub_0_1")
+	defer krnl.Release()
+
+	regionBuff := world.Malloc(xcl.ReadWrite, REGION_WORDS*8)
+	defer regionBuff.Free()
+
+	var results [(RESULT_HEADER_SIZE + MAX_CLIENTS*RESULT_ROW_SIZE) / 8]uint64
+	resultBuff := world.Malloc(xcl.WriteOnly, uint(binary.Size(results)))
+	defer resultBuff.Free()
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Clients\tClient\tWrites\tReads\tTag errors\tAddress errors\tOwn write errors\tOrder errors\t\n")
+	for numClients := uint32(2); numClients <= MAX_CLIENTS; numClients++ {
+		krnl.SetMemoryArg(0, regionBuff)
+		krnl.SetArg(1, uint32(REGION_WORDS))
+		krnl.SetArg(2, uint32(ITERATIONS))
+		krnl.SetArg(3, numClients)
+		krnl.SetMemoryArg(4, resultBuff)
+
+		krnl.Run()
+
+		err := binary.Read(resultBuff.Reader(), binary.LittleEndian, &results)
+		if err != nil {
+			log.Fatal("binary.Read failed:", err)
+		}
+
+		if results[0] != 0 {
+			log.Printf("%d clients: %d words of the region were wrong at the end", numClients, results[0])
+			failed = true
+		}
+		for i := 0; i < int(numClients); i++ {
+			row := results[(RESULT_HEADER_SIZE+i*RESULT_ROW_SIZE)/8:]
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+				numClients, i, row[0], row[1], row[2], row[3], row[4], row[5])
+			if row[2]|row[3]|row[4]|row[5] != 0 {
+				failed = true
+			}
+		}
+	}
+	w.Flush()
+
+	if failed {
+		log.Fatal("Coherence errors detected.")
+	}
+}
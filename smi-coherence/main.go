@@ -0,0 +1,338 @@
+package main
+
+import (
+	"github.com/ReconfigureIO/sdaccel/smi"
+)
+
+// Limits on the kernel arguments. The shared region is read back in a single
+// burst, so it must fit in one.
+const (
+	maxClients    = 4
+	maxWords      = smi.SmiMemBurstSize / 8
+	maxIterations = 255
+)
+
+// Layout of the results. A header of 8 64-bit words holds the number of
+// errors found in the final contents of the region and the number of
+// clients, followed by a row of 8 64-bit words for each client: the number
+// of writes, the number of words read, then the tag, address, own write
+// and ordering error counts.
+const (
+	resultHeaderSize = 64
+	resultRowSize    = 64
+)
+
+// Structure for holding the counts for one client.
+type resultType struct {
+	writes        uint32
+	reads         uint32
+	tagErrors     uint32
+	addressErrors uint32
+	ownErrors     uint32
+	orderErrors   uint32
+}
+
+// Function for writing a byte with a raw SMI request frame, carrying the
+// given tag. Returns true if the response frame is a successful write
+// response with the same tag.
+func writeByte(smiRequest chan<- smi.Flit64, smiResponse <-chan smi.Flit64,
+	tag uint16, addr uintptr, value uint8) bool {
+
+	smiRequest <- smi.Flit64{
+		Eofc: 0,
+		Data: [8]uint8{
+			uint8(smi.SmiMemWriteReq), uint8(smi.DefaultOptions),
+			uint8(tag), uint8(tag >> 8),
+			uint8(addr), uint8(addr >> 8), uint8(addr >> 16), uint8(addr >> 24)}}
+	smiRequest <- smi.Flit64{
+		Eofc: 7,
+		Data: [8]uint8{
+			uint8(addr >> 32), uint8(addr >> 40), uint8(addr >> 48), uint8(addr >> 56),
+			1, 0, value, 0}}
+
+	// Accept the whole response frame, whatever its length.
+	respFlit := <-smiResponse
+	frameOk := respFlit.Data[0] == smi.SmiMemWriteResp &&
+		respFlit.Data[1]&0x02 == 0 &&
+		respFlit.Data[2] == uint8(tag) && respFlit.Data[3] == uint8(tag>>8) &&
+		respFlit.Eofc == 4
+	for respFlit.Eofc == 0 {
+		respFlit = <-smiResponse
+	}
+	return frameOk
+}
+
+// Function for reading the specified number of 64-bit words with a raw SMI
+// request frame, carrying the given tag, and sending them on words. Returns
+// true if the response frame is a successful read response with the same
+// tag and the expected length. Missing words are sent as zero.
+func readWords(smiRequest chan<- smi.Flit64, smiResponse <-chan smi.Flit64,
+	tag uint16, addr uintptr, length uint32, words chan<- uint64) bool {
+
+	size := length << 3
+	smiRequest <- smi.Flit64{
+		Eofc: 0,
+		Data: [8]uint8{
+			uint8(smi.SmiMemReadReq), uint8(smi.DefaultOptions),
+			uint8(tag), uint8(tag >> 8),
+			uint8(addr), uint8(addr >> 8), uint8(addr >> 16), uint8(addr >> 24)}}
+	smiRequest <- smi.Flit64{
+		Eofc: 6,
+		Data: [8]uint8{
+			uint8(addr >> 32), uint8(addr >> 40), uint8(addr >> 48), uint8(addr >> 56),
+			uint8(size), uint8(size >> 8), 0, 0}}
+
+	// The data follows the 4 byte header, so each word is the top half of
+	// one flit and the bottom half of the next.
+	respFlit := <-smiResponse
+	frameOk := respFlit.Data[0] == smi.SmiMemReadResp &&
+		respFlit.Data[1]&0x02 == 0 &&
+		respFlit.Data[2] == uint8(tag) && respFlit.Data[3] == uint8(tag>>8)
+	lastFlit := respFlit
+	count := uint32(0)
+	for respFlit.Eofc == 0 {
+		respFlit = <-smiResponse
+		if count != length {
+			words <- uint64(lastFlit.Data[4]) | uint64(lastFlit.Data[5])<<8 |
+				uint64(lastFlit.Data[6])<<16 | uint64(lastFlit.Data[7])<<24 |
+				uint64(respFlit.Data[0])<<32 | uint64(respFlit.Data[1])<<40 |
+				uint64(respFlit.Data[2])<<48 | uint64(respFlit.Data[3])<<56
+		}
+		count += 1
+		lastFlit = respFlit
+	}
+	for i := count; i < length; i++ {
+		words <- 0
+	}
+	return frameOk && count == length && respFlit.Eofc == 4
+}
+
+// Function implementing one client of the shared memory port. On each
+// iteration the client writes its own byte lane of every word in the shared
+// region, starting from a different word to the other clients, and reads
+// the words back, checking them against the ordering rules:
+//
+//   - Every response carries the tag of the client's request.
+//   - The top half of every word holds its own address, which nothing writes.
+//     The arbiter restores each response's tag from its own table, so a
+//     response routed to the wrong port may still carry the right tag, but
+//     it holds the wrong addresses.
+//   - The client's own lane holds the value it last wrote there.
+//   - Every other client's lane holds a value that client could have written,
+//     and never goes backwards between reads by this client.
+//   - The lanes of clients which aren't running stay zero.
+//
+// On odd iterations each word is read back as soon as it is written. On
+// even iterations the whole region is read back in a single burst once all
+// of the words have been written. The counts are returned via resultChan.
+func client(smiRequest chan<- smi.Flit64, smiResponse <-chan smi.Flit64,
+	clientId uint32, numClients uint32, regionPtr uintptr, regionWords uint32,
+	numIterations uint32, resultChan chan<- resultType) {
+
+	result := resultType{}
+	// The last value seen in each client's lane of each word
+	var lastSeen [maxWords * maxClients]uint8
+	sequence := uint8(0)
+	startWord := clientId * regionWords / numClients
+
+	// Function for checking a word read back from the region.
+	checkWord := func(wordIndex uint32, word uint64, value uint8) {
+		addr := regionPtr + uintptr(wordIndex<<3)
+		if uint32(word>>32) != uint32(addr) {
+			result.addressErrors += 1
+		}
+		for lane := uint32(0); lane != maxClients; lane++ {
+			laneValue := uint8(word >> (lane << 3))
+			seen := &lastSeen[wordIndex*maxClients+lane]
+			if lane == clientId {
+				if laneValue != value {
+					result.ownErrors += 1
+				}
+			} else if lane >= numClients {
+				if laneValue != 0 {
+					result.addressErrors += 1
+				}
+			} else if uint32(laneValue) > numIterations || laneValue < *seen {
+				result.orderErrors += 1
+			}
+			*seen = laneValue
+		}
+		result.reads += 1
+	}
+
+	for iteration := uint32(1); iteration <= numIterations; iteration++ {
+		value := uint8(iteration)
+		for i := uint32(0); i != regionWords; i++ {
+			wordIndex := startWord + i
+			if wordIndex >= regionWords {
+				wordIndex -= regionWords
+			}
+			addr := regionPtr + uintptr(wordIndex<<3)
+
+			tag := uint16(clientId)<<8 | uint16(sequence)
+			sequence += 1
+			if !writeByte(smiRequest, smiResponse, tag, addr+uintptr(clientId), value) {
+				result.tagErrors += 1
+			}
+			result.writes += 1
+
+			if iteration&1 == 1 {
+				wordChan := make(chan uint64, 1)
+				tag := uint16(clientId)<<8 | uint16(sequence)
+				sequence += 1
+				if !readWords(smiRequest, smiResponse, tag, addr, 1, wordChan) {
+					result.tagErrors += 1
+				}
+				checkWord(wordIndex, <-wordChan, value)
+			}
+		}
+
+		if iteration&1 == 0 {
+			wordChan := make(chan uint64, maxWords)
+			tag := uint16(clientId)<<8 | uint16(sequence)
+			sequence += 1
+			if !readWords(smiRequest, smiResponse, tag, regionPtr, regionWords, wordChan) {
+				result.tagErrors += 1
+			}
+			for wordIndex := uint32(0); wordIndex != regionWords; wordIndex++ {
+				checkWord(wordIndex, <-wordChan, value)
+			}
+		}
+	}
+	resultChan <- result
+}
+
+// Top level, with one SMI port shared between the clients through an
+// arbiter, and one for setting up and checking the region and writing the
+// results.
+func Top(
+	// Pointer to the shared region of 64-bit words
+	regionPtr uintptr,
+	// Number of words in the shared region, up to 32
+	regionWords uint32,
+	// Number of passes each client makes over the region, up to 255
+	numIterations uint32,
+	// Number of clients sharing the memory port, from 2 to 4
+	numClients uint32,
+	// Pointer to the results
+	resultPtr uintptr,
+
+	// SMI channels shared by the clients
+	sharedReq chan<- smi.Flit64,
+	sharedResp <-chan smi.Flit64,
+
+	// SMI channels for setting up and checking the region, and writing the
+	// results
+	resultReq chan<- smi.Flit64,
+	resultResp <-chan smi.Flit64) {
+
+	if regionWords > maxWords {
+		regionWords = maxWords
+	}
+	if numIterations > maxIterations {
+		numIterations = maxIterations
+	}
+	if numClients < 2 {
+		numClients = 2
+	}
+	if numClients > maxClients {
+		numClients = maxClients
+	}
+
+	// Initialise each word of the region with its address in the top half.
+	initChan := make(chan uint64, maxWords)
+	for i := uint32(0); i != regionWords; i++ {
+		initChan <- uint64(uint32(regionPtr+uintptr(i<<3))) << 32
+	}
+	smi.WriteBurstUInt64(resultReq, resultResp, regionPtr, smi.DefaultOptions,
+		regionWords, initChan)
+
+	// Connect the clients to the shared port through the arbiter for their
+	// number.
+	clientReqA := make(chan smi.Flit64, 1)
+	clientRespA := make(chan smi.Flit64, 1)
+	clientReqB := make(chan smi.Flit64, 1)
+	clientRespB := make(chan smi.Flit64, 1)
+	clientReqC := make(chan smi.Flit64, 1)
+	clientRespC := make(chan smi.Flit64, 1)
+	clientReqD := make(chan smi.Flit64, 1)
+	clientRespD := make(chan smi.Flit64, 1)
+	switch numClients {
+	case 2:
+		go smi.ArbitrateX2(clientReqA, clientRespA, clientReqB, clientRespB,
+			sharedReq, sharedResp)
+	case 3:
+		go smi.ArbitrateX3(clientReqA, clientRespA, clientReqB, clientRespB,
+			clientReqC, clientRespC, sharedReq, sharedResp)
+	default:
+		go smi.ArbitrateX4(clientReqA, clientRespA, clientReqB, clientRespB,
+			clientReqC, clientRespC, clientReqD, clientRespD, sharedReq, sharedResp)
+	}
+
+	// Run the clients in parallel.
+	resultChanA := make(chan resultType, 1)
+	resultChanB := make(chan resultType, 1)
+	resultChanC := make(chan resultType, 1)
+	resultChanD := make(chan resultType, 1)
+	go client(clientReqA, clientRespA, 0, numClients, regionPtr, regionWords,
+		numIterations, resultChanA)
+	go client(clientReqB, clientRespB, 1, numClients, regionPtr, regionWords,
+		numIterations, resultChanB)
+	if numClients >= 3 {
+		go client(clientReqC, clientRespC, 2, numClients, regionPtr, regionWords,
+			numIterations, resultChanC)
+	} else {
+		resultChanC <- resultType{}
+	}
+	if numClients == 4 {
+		go client(clientReqD, clientRespD, 3, numClients, regionPtr, regionWords,
+			numIterations, resultChanD)
+	} else {
+		resultChanD <- resultType{}
+	}
+	resultA := <-resultChanA
+	resultB := <-resultChanB
+	resultC := <-resultChanC
+	resultD := <-resultChanD
+
+	// Once the clients have finished, every running client's lane of every
+	// word holds its final value.
+	finalErrors := uint32(0)
+	finalChan := make(chan uint64, maxWords)
+	go smi.ReadBurstUInt64(resultReq, resultResp, regionPtr, smi.DefaultOptions,
+		regionWords, finalChan)
+	for i := uint32(0); i != regionWords; i++ {
+		word := <-finalChan
+		expected := uint64(uint32(regionPtr+uintptr(i<<3))) << 32
+		for lane := uint32(0); lane != numClients; lane++ {
+			expected |= uint64(uint8(numIterations)) << (lane << 3)
+		}
+		if word != expected {
+			finalErrors += 1
+		}
+	}
+
+	// Write the results.
+	resultChan := make(chan uint64, (resultHeaderSize+maxClients*resultRowSize)/8)
+	resultChan <- uint64(finalErrors)
+	resultChan <- uint64(numClients)
+	for i := 2; i != resultHeaderSize/8; i++ {
+		resultChan <- 0
+	}
+	writeRow := func(result resultType) {
+		resultChan <- uint64(result.writes)
+		resultChan <- uint64(result.reads)
+		resultChan <- uint64(result.tagErrors)
+		resultChan <- uint64(result.addressErrors)
+		resultChan <- uint64(result.ownErrors)
+		resultChan <- uint64(result.orderErrors)
+		resultChan <- 0
+		resultChan <- 0
+	}
+	writeRow(resultA)
+	writeRow(resultB)
+	writeRow(resultC)
+	writeRow(resultD)
+	smi.WriteBurstUInt64(resultReq, resultResp, resultPtr, smi.DefaultOptions,
+		(resultHeaderSize+maxClients*resultRowSize)/8, resultChan)
+}
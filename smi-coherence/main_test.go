@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/ReconfigureIO/sdaccel/smi"
+	"github.com/ReconfigureIO/sdaccel/smi/smicheck"
+	"github.com/ReconfigureIO/sdaccel/smi/smitest"
+)
+
+const (
+	regionPtr     = 0x100000
+	regionWords   = 32
+	numIterations = 6
+	resultPtr     = 0x200000
+)
+
+// results holds the decoded results.
+type results struct {
+	finalErrors uint64
+	clients     [maxClients]resultType
+}
+
+// run runs Top on mem for the given number of clients, serving the shared
+// port with shared, and returns the decoded results.
+func run(mem *smitest.Memory, numClients uint32, shared func() (chan<- smi.Flit64, <-chan smi.Flit64)) results {
+	sharedReq, sharedResp := shared()
+	resultReq, resultResp := mem.Port()
+	Top(regionPtr, regionWords, numIterations, numClients, resultPtr,
+		sharedReq, sharedResp, resultReq, resultResp)
+
+	b := mem.Read(resultPtr, resultHeaderSize+maxClients*resultRowSize)
+	word := func(i int) uint32 {
+		return uint32(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	r := results{finalErrors: uint64(word(0))}
+	for i := range r.clients {
+		base := (resultHeaderSize + i*resultRowSize) / 8
+		r.clients[i] = resultType{word(base), word(base + 1), word(base + 2),
+			word(base + 3), word(base + 4), word(base + 5)}
+	}
+	return r
+}
+
+func TestCoherence(t *testing.T) {
+	for numClients := uint32(2); numClients <= maxClients; numClients++ {
+		mem := smitest.NewMemory()
+		// Check the arbitrated traffic on the shared port too.
+		checker := smicheck.NewChecker(smicheck.Config{Arbitrated: map[uint8]bool{0: true}})
+		r := run(mem, numClients, func() (chan<- smi.Flit64, <-chan smi.Flit64) {
+			memReq, memResp := mem.Port()
+			return checker.Monitor(0, memReq, memResp)
+		})
+		for _, v := range checker.Finish() {
+			t.Errorf("%d clients: %v", numClients, v)
+		}
+		if r.finalErrors != 0 {
+			t.Errorf("%d clients: %d words wrong at the end", numClients, r.finalErrors)
+		}
+		for i, c := range r.clients {
+			expected := resultType{}
+			if uint32(i) < numClients {
+				// Half of the iterations read each word once, the other
+				// half read the whole region.
+				expected.writes = numIterations * regionWords
+				expected.reads = numIterations * regionWords
+			}
+			if c != expected {
+				t.Errorf("%d clients: client %d counted %+v, expected %+v", numClients, i, c, expected)
+			}
+		}
+	}
+}
+
+// swappingPort serves an SMI port on mem, swapping the tags of pairs of
+// read responses of the same length, so that the arbiter routes each to the
+// other's port. A response which can't be paired is sent after a timeout.
+func swappingPort(mem *smitest.Memory) (chan<- smi.Flit64, <-chan smi.Flit64) {
+	req := make(chan smi.Flit64)
+	resp := make(chan smi.Flit64)
+	frames := make(chan []byte)
+	go func() {
+		for {
+			frame, ok := smitest.ReadFrame(req)
+			if !ok {
+				close(frames)
+				return
+			}
+			frames <- frame
+		}
+	}()
+	send := func(flits []smi.Flit64) {
+		for _, flit := range flits {
+			resp <- flit
+		}
+	}
+	go func() {
+		var held []smi.Flit64
+		for {
+			var timeout <-chan time.Time
+			if held != nil {
+				timeout = time.After(10 * time.Millisecond)
+			}
+			select {
+			case frame, ok := <-frames:
+				if !ok {
+					return
+				}
+				flits := mem.Handle(frame)
+				switch {
+				case flits[0].Data[0] != smi.SmiMemReadResp:
+					send(flits)
+				case held == nil:
+					held = flits
+				case len(held) == len(flits) && held[0].Data[2] != flits[0].Data[2]:
+					held[0].Data[2], flits[0].Data[2] = flits[0].Data[2], held[0].Data[2]
+					held[0].Data[3], flits[0].Data[3] = flits[0].Data[3], held[0].Data[3]
+					send(held)
+					send(flits)
+					held = nil
+				default:
+					send(held)
+					held = flits
+				}
+			case <-timeout:
+				send(held)
+				held = nil
+			}
+		}
+	}()
+	return req, resp
+}
+
+func TestMisrouting(t *testing.T) {
+	mem := smitest.NewMemory()
+	r := run(mem, 4, func() (chan<- smi.Flit64, <-chan smi.Flit64) {
+		return swappingPort(mem)
+	})
+	// The arbiter restores the tags, so the misrouted responses show up
+	// as reads of the wrong addresses.
+	addressErrors := uint32(0)
+	for _, c := range r.clients {
+		addressErrors += c.addressErrors
+	}
+	if addressErrors == 0 {
+		t.Errorf("misrouted responses weren't detected: %+v", r.clients)
+	}
+}
+
+// losingPort serves an SMI port on mem, acknowledging but dropping the
+// write to addr.
+func losingPort(mem *smitest.Memory, addr uint64) (chan<- smi.Flit64, <-chan smi.Flit64) {
+	req := make(chan smi.Flit64)
+	resp := make(chan smi.Flit64)
+	go func() {
+		for {
+			frame, ok := smitest.ReadFrame(req)
+			if !ok {
+				return
+			}
+			if frame[0] == smi.SmiMemWriteReq && binary.LittleEndian.Uint64(frame[4:]) == addr {
+				frame = []byte{smi.SmiMemWriteReq, 0, frame[2], frame[3], 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+			}
+			for _, flit := range mem.Handle(frame) {
+				resp <- flit
+			}
+		}
+	}()
+	return req, resp
+}
+
+func TestLostWrite(t *testing.T) {
+	// Client 1's writes to word 5 never land.
+	mem := smitest.NewMemory()
+	r := run(mem, 2, func() (chan<- smi.Flit64, <-chan smi.Flit64) {
+		return losingPort(mem, regionPtr+5*8+1)
+	})
+	if r.clients[1].ownErrors == 0 || r.clients[0].ownErrors != 0 || r.finalErrors != 1 {
+		t.Errorf("lost write wasn't detected: %d final errors, %+v", r.finalErrors, r.clients)
+	}
+}
+
+func TestFailedResponses(t *testing.T) {
+	mem := smitest.NewMemory()
+	var port *smitest.FaultyPort
+	r := run(mem, 3, func() (chan<- smi.Flit64, <-chan smi.Flit64) {
+		port = smitest.NewFaultyPort(mem, smitest.Faults{Seed: 3, Error: 0.05})
+		return port.Req, port.Resp
+	})
+	// Every failed request is counted once, by the client which made it.
+	tagErrors := 0
+	for _, c := range r.clients {
+		tagErrors += int(c.tagErrors)
+	}
+	if injected := port.Count(smitest.FaultError); injected == 0 || tagErrors != injected {
+		t.Errorf("%d errors were injected, but %d were counted: %+v", injected, tagErrors, r.clients)
+	}
+}